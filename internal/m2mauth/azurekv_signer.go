@@ -0,0 +1,239 @@
+/*
+ * Copyright (c) 2023-Present, Okta, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package m2mauth
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+const azureKeyVaultAPIVersion = "7.4"
+
+// azureKeyVaultSigner calls Azure Key Vault's sign API over its REST
+// interface. Credentials follow Azure's usual environment variable
+// conventions (AZURE_TENANT_ID/AZURE_CLIENT_ID/AZURE_CLIENT_SECRET),
+// falling back to the Azure Instance Metadata Service managed identity
+// endpoint when they aren't set.
+type azureKeyVaultSigner struct {
+	httpClient *http.Client
+	vaultURL   string // e.g. https://my-vault.vault.azure.net
+	keyPath    string // keyName[/keyVersion]
+	kid        string
+	alg        jose.SignatureAlgorithm
+	hash       func() hash.Hash
+	publicKey  jose.JSONWebKey
+}
+
+// newAzureKeyVaultSigner builds a Signer backed by the Azure Key Vault key
+// identified by rest, "<vault name>/<key name>[/<key version>]". It fetches
+// the key's metadata once at construction time, since Key Vault returns both
+// the key type and its public JWK from the same GET.
+func newAzureKeyVaultSigner(rest, kid string, httpClient *http.Client) (Signer, error) {
+	parts := strings.SplitN(rest, "/", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("azure key vault signing backend %q must be azurekv://<vault>/<key>[/<version>]", rest)
+	}
+
+	s := &azureKeyVaultSigner{
+		httpClient: httpClient,
+		vaultURL:   fmt.Sprintf("https://%s.vault.azure.net", parts[0]),
+		keyPath:    parts[1],
+		kid:        kid,
+	}
+	if s.kid == "" {
+		s.kid = rest
+	}
+
+	token, err := s.accessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	// Key Vault's key metadata response embeds the public key as a
+	// standard JWK (kty/n/e for RSA, kty/crv/x/y for EC), so go-jose can
+	// parse it directly into a usable crypto.PublicKey.
+	var keyResp struct {
+		Key jose.JSONWebKey `json:"key"`
+	}
+	if err := s.do(token, "GET", s.keyPath+"?api-version="+azureKeyVaultAPIVersion, nil, &keyResp); err != nil {
+		return nil, fmt.Errorf("describing azure key vault key %q: %w", rest, err)
+	}
+	s.publicKey = keyResp.Key
+	s.publicKey.KeyID = s.kid
+	s.publicKey.Use = "sig"
+
+	switch key := keyResp.Key.Key.(type) {
+	case *rsa.PublicKey:
+		s.alg, s.hash = jose.RS256, sha256.New
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			s.alg, s.hash = jose.ES256, sha256.New
+		case elliptic.P384():
+			s.alg, s.hash = jose.ES384, sha512.New384
+		case elliptic.P521():
+			s.alg, s.hash = jose.ES512, sha512.New
+		default:
+			return nil, fmt.Errorf("azure key vault key %q has unsupported curve %s", rest, key.Curve.Params().Name)
+		}
+	default:
+		return nil, fmt.Errorf("azure key vault key %q has unsupported key type %T", rest, keyResp.Key.Key)
+	}
+	s.publicKey.Algorithm = string(s.alg)
+
+	return s, nil
+}
+
+func (s *azureKeyVaultSigner) algAndKid() (jose.SignatureAlgorithm, string) {
+	return s.alg, s.kid
+}
+
+func (s *azureKeyVaultSigner) publicJWK() (*jose.JSONWebKey, error) {
+	return &s.publicKey, nil
+}
+
+// Sign implements Signer by hashing payload locally and calling Key
+// Vault's sign API on the digest. Key Vault returns EC signatures already
+// in the raw r||s format JOSE requires.
+func (s *azureKeyVaultSigner) Sign(payload []byte) ([]byte, jose.SignatureAlgorithm, string, error) {
+	token, err := s.accessToken()
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	h := s.hash()
+	h.Write(payload)
+	digest := h.Sum(nil)
+
+	reqBody := map[string]string{
+		"alg":   string(s.alg),
+		"value": base64.RawURLEncoding.EncodeToString(digest),
+	}
+
+	var signResp struct {
+		Value string `json:"value"`
+	}
+	if err := s.do(token, "POST", s.keyPath+"/sign?api-version="+azureKeyVaultAPIVersion, reqBody, &signResp); err != nil {
+		return nil, "", "", fmt.Errorf("signing with azure key vault key %q: %w", s.keyPath, err)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signResp.Value)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("decoding azure key vault signature: %w", err)
+	}
+
+	return sig, s.alg, s.kid, nil
+}
+
+// accessToken obtains a bearer token scoped to https://vault.azure.net, via
+// a client credentials grant when AZURE_CLIENT_ID/AZURE_CLIENT_SECRET/
+// AZURE_TENANT_ID are set, falling back to the managed identity available
+// through the Azure Instance Metadata Service.
+func (s *azureKeyVaultSigner) accessToken() (string, error) {
+	tenantID := os.Getenv("AZURE_TENANT_ID")
+	clientID := os.Getenv("AZURE_CLIENT_ID")
+	clientSecret := os.Getenv("AZURE_CLIENT_SECRET")
+
+	var req *http.Request
+	var err error
+	if tenantID != "" && clientID != "" && clientSecret != "" {
+		form := url.Values{
+			"grant_type":    {"client_credentials"},
+			"client_id":     {clientID},
+			"client_secret": {clientSecret},
+			"scope":         {"https://vault.azure.net/.default"},
+		}
+		tokenURL := fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", tenantID)
+		req, err = http.NewRequest("POST", tokenURL, strings.NewReader(form.Encode()))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	} else {
+		imdsURL := "http://169.254.169.254/metadata/identity/oauth2/token?api-version=2018-02-01&resource=" + url.QueryEscape("https://vault.azure.net")
+		req, err = http.NewRequest("GET", imdsURL, nil)
+		if err == nil {
+			req.Header.Set("Metadata", "true")
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetching azure access token: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("azure token endpoint returned %q", resp.Status)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", err
+	}
+	return tokenResp.AccessToken, nil
+}
+
+func (s *azureKeyVaultSigner) do(token, method, path string, body, out interface{}) error {
+	var reqBody *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	} else {
+		reqBody = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, s.vaultURL+"/keys/"+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("azure key vault API returned %q", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,223 @@
+/*
+ * Copyright (c) 2023-Present, Okta, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package m2mauth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// gcpKMSSigner calls Cloud KMS's asymmetricSign API over its REST
+// interface. By default it authenticates as the GCE/GKE/Cloud Run default
+// service account via the metadata server; GOOGLE_OAUTH_ACCESS_TOKEN
+// overrides this with a pre-fetched token.
+type gcpKMSSigner struct {
+	client           *http.Client
+	cryptoKeyVersion string
+	kid              string
+	alg              jose.SignatureAlgorithm
+	hash             func() hash.Hash
+	ecdsaCoordSize   int // 0 for RSA keys
+	publicKey        jose.JSONWebKey
+}
+
+// newGCPKMSSigner builds a Signer backed by the GCP Cloud KMS
+// CryptoKeyVersion resource name cryptoKeyVersion, e.g.
+// "projects/p/locations/l/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1". It
+// looks up the version's algorithm and public key once at construction
+// time so that Sign does not have to look them up again; each Sign call
+// still obtains an access token before its asymmetricSign call.
+func newGCPKMSSigner(cryptoKeyVersion, kid string, httpClient *http.Client) (Signer, error) {
+	s := &gcpKMSSigner{client: httpClient, cryptoKeyVersion: cryptoKeyVersion, kid: kid}
+	if s.kid == "" {
+		s.kid = cryptoKeyVersion
+	}
+
+	var keyResp struct {
+		Algorithm string `json:"algorithm"`
+	}
+	if err := s.call("GET", cryptoKeyVersion, nil, &keyResp); err != nil {
+		return nil, fmt.Errorf("describing GCP KMS key %q: %w", cryptoKeyVersion, err)
+	}
+
+	switch keyResp.Algorithm {
+	case "RSA_SIGN_PKCS1_2048_SHA256", "RSA_SIGN_PKCS1_3072_SHA256", "RSA_SIGN_PKCS1_4096_SHA256":
+		s.alg, s.hash = jose.RS256, sha256.New
+	case "EC_SIGN_P256_SHA256":
+		s.alg, s.hash, s.ecdsaCoordSize = jose.ES256, sha256.New, 32
+	case "EC_SIGN_P384_SHA384":
+		s.alg, s.hash, s.ecdsaCoordSize = jose.ES384, sha512.New384, 48
+	default:
+		return nil, fmt.Errorf("GCP KMS key %q has unsupported algorithm %q", cryptoKeyVersion, keyResp.Algorithm)
+	}
+
+	pub, err := s.fetchPublicKey()
+	if err != nil {
+		return nil, err
+	}
+	s.publicKey = jose.JSONWebKey{Key: pub, KeyID: s.kid, Algorithm: string(s.alg), Use: "sig"}
+
+	return s, nil
+}
+
+func (s *gcpKMSSigner) algAndKid() (jose.SignatureAlgorithm, string) {
+	return s.alg, s.kid
+}
+
+func (s *gcpKMSSigner) publicJWK() (*jose.JSONWebKey, error) {
+	return &s.publicKey, nil
+}
+
+// fetchPublicKey retrieves the PEM-encoded public key for s.cryptoKeyVersion
+// from Cloud KMS's publicKey API and parses it into a crypto.PublicKey.
+func (s *gcpKMSSigner) fetchPublicKey() (interface{}, error) {
+	var pubResp struct {
+		Pem string `json:"pem"`
+	}
+	if err := s.call("GET", s.cryptoKeyVersion+"/publicKey", nil, &pubResp); err != nil {
+		return nil, fmt.Errorf("fetching public key for GCP KMS key %q: %w", s.cryptoKeyVersion, err)
+	}
+
+	block, _ := pem.Decode([]byte(pubResp.Pem))
+	if block == nil {
+		return nil, fmt.Errorf("GCP KMS key %q returned an unparseable public key PEM", s.cryptoKeyVersion)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key for GCP KMS key %q: %w", s.cryptoKeyVersion, err)
+	}
+	return pub, nil
+}
+
+// Sign implements Signer by hashing payload locally and calling Cloud
+// KMS's asymmetricSign API on the digest. ECDSA signatures come back DER
+// encoded and are converted to the fixed-width raw format JOSE expects.
+func (s *gcpKMSSigner) Sign(payload []byte) ([]byte, jose.SignatureAlgorithm, string, error) {
+	h := s.hash()
+	h.Write(payload)
+	digest := h.Sum(nil)
+
+	digestField := "sha256"
+	if s.alg == jose.ES384 {
+		digestField = "sha384"
+	}
+
+	reqBody := map[string]interface{}{
+		"digest": map[string]string{digestField: base64.StdEncoding.EncodeToString(digest)},
+	}
+
+	var signResp struct {
+		Signature string `json:"signature"`
+	}
+	if err := s.call("POST", s.cryptoKeyVersion+":asymmetricSign", reqBody, &signResp); err != nil {
+		return nil, "", "", fmt.Errorf("signing with GCP KMS key %q: %w", s.cryptoKeyVersion, err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signResp.Signature)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("decoding GCP KMS signature: %w", err)
+	}
+	if s.ecdsaCoordSize > 0 {
+		sig, err = ecdsaDERToJOSE(sig, s.ecdsaCoordSize)
+		if err != nil {
+			return nil, "", "", err
+		}
+	}
+
+	return sig, s.alg, s.kid, nil
+}
+
+func (s *gcpKMSSigner) call(method, path string, body, out interface{}) error {
+	token, err := s.accessToken()
+	if err != nil {
+		return err
+	}
+
+	var reqBody *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(b)
+	} else {
+		reqBody = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, "https://cloudkms.googleapis.com/v1/"+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GCP KMS API returned %q", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// accessToken returns GOOGLE_OAUTH_ACCESS_TOKEN when set, otherwise fetches
+// a token for the environment's default service account from the GCE
+// metadata server.
+func (s *gcpKMSSigner) accessToken() (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	req, err := http.NewRequest("GET", "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetching GCP access token from metadata server: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GCP metadata server returned %q", resp.Status)
+	}
+
+	var tokenResp struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", err
+	}
+	return tokenResp.AccessToken, nil
+}
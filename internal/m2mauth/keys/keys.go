@@ -0,0 +1,218 @@
+/*
+ * Copyright (c) 2023-Present, Okta, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package keys manages the small rotating set of keys okta-aws-cli signs
+// the M2M client assertion JWT with: one active signing key plus a handful
+// of recently-retired keys that are kept around, and published, only long
+// enough for Okta to stop seeing tokens signed with them.
+package keys
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// KeyType selects the algorithm RotateKeys generates a new key with.
+type KeyType string
+
+const (
+	// RSA2048 A 2048 bit RSA key, signed with RS256.
+	RSA2048 KeyType = "rsa2048"
+	// ECP256 An EC P-256 key, signed with ES256.
+	ECP256 KeyType = "ecp256"
+)
+
+// Key is one generation of signing key. Retired keys are kept, and
+// published, until they pass Expiry so that tokens signed before a
+// rotation can still be verified.
+type Key struct {
+	KID       string                  `json:"kid"`
+	Algorithm jose.SignatureAlgorithm `json:"alg"`
+	Created   time.Time               `json:"created"`
+	Expiry    time.Time               `json:"expiry"`
+	Retired   bool                    `json:"retired"`
+	JWK       jose.JSONWebKey         `json:"jwk"`
+}
+
+// Public returns k's public key as a JWK suitable for publishing.
+func (k Key) Public() jose.JSONWebKey {
+	return k.JWK.Public()
+}
+
+// Manager persists a set of Keys to a JSON file on disk and rotates them.
+type Manager struct {
+	path string
+	now  func() time.Time
+	keys []Key
+}
+
+// NewManager loads the key set persisted at path, creating an empty one if
+// path does not yet exist.
+func NewManager(path string) (*Manager, error) {
+	m := &Manager{path: path, now: time.Now}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading key store %q: %w", path, err)
+	}
+
+	if err := json.Unmarshal(b, &m.keys); err != nil {
+		return nil, fmt.Errorf("parsing key store %q: %w", path, err)
+	}
+	return m, nil
+}
+
+// Active returns the current signing key, i.e. the most recently created
+// key that has not been retired. Callers should RotateKeys first if no key
+// has ever been generated.
+func (m *Manager) Active() (*Key, error) {
+	var active *Key
+	for i := range m.keys {
+		k := &m.keys[i]
+		if k.Retired {
+			continue
+		}
+		if active == nil || k.Created.After(active.Created) {
+			active = k
+		}
+	}
+	if active == nil {
+		return nil, errors.New("no active signing key, run RotateKeys first")
+	}
+	return active, nil
+}
+
+// All returns every key currently tracked, active and retired, newest
+// first.
+func (m *Manager) All() []Key {
+	all := make([]Key, len(m.keys))
+	copy(all, m.keys)
+	sort.Slice(all, func(i, j int) bool { return all[i].Created.After(all[j].Created) })
+	return all
+}
+
+// JWKS returns the public keys of every tracked key (active and retired)
+// as a JWKS, for publishing at e.g. /.well-known/jwks.json.
+func (m *Manager) JWKS() jose.JSONWebKeySet {
+	set := jose.JSONWebKeySet{}
+	for _, k := range m.All() {
+		set.Keys = append(set.Keys, k.Public())
+	}
+	return set
+}
+
+// RotateKeys generates a new signing key of keyType, retires the
+// previously active key (keeping it published for gracePeriod so tokens
+// already in flight still verify), and drops any retired key whose grace
+// period has elapsed. The new key becomes active immediately and expires,
+// i.e. is due for its own rotation, after ttl.
+func (m *Manager) RotateKeys(keyType KeyType, ttl, gracePeriod time.Duration) (*Key, error) {
+	now := m.now()
+
+	newKey, err := generateKey(keyType, now, ttl)
+	if err != nil {
+		return nil, err
+	}
+
+	var kept []Key
+	for _, k := range m.keys {
+		if !k.Retired {
+			k.Retired = true
+			k.Expiry = now.Add(gracePeriod)
+		}
+		if k.Retired && now.After(k.Expiry) {
+			continue // past its grace window, drop it
+		}
+		kept = append(kept, k)
+	}
+	kept = append(kept, *newKey)
+	m.keys = kept
+
+	if err := m.save(); err != nil {
+		return nil, err
+	}
+	return newKey, nil
+}
+
+func (m *Manager) save() error {
+	b, err := json.MarshalIndent(m.keys, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.path), 0700); err != nil {
+		return fmt.Errorf("creating key store directory: %w", err)
+	}
+	if err := os.WriteFile(m.path, b, 0600); err != nil {
+		return fmt.Errorf("writing key store %q: %w", m.path, err)
+	}
+	return nil
+}
+
+// newKID returns a random key ID for a freshly generated key.
+func newKID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating kid: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func generateKey(keyType KeyType, now time.Time, ttl time.Duration) (*Key, error) {
+	kid, err := newKID()
+	if err != nil {
+		return nil, err
+	}
+
+	var key interface{}
+	var alg jose.SignatureAlgorithm
+	switch keyType {
+	case RSA2048, "":
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		alg = jose.RS256
+	case ECP256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		alg = jose.ES256
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", keyType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("generating %s key: %w", keyType, err)
+	}
+
+	return &Key{
+		KID:       kid,
+		Algorithm: alg,
+		Created:   now,
+		Expiry:    now.Add(ttl),
+		JWK:       jose.JSONWebKey{Key: key, KeyID: kid, Algorithm: string(alg), Use: "sig"},
+	}, nil
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2023-Present, Okta, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package keys
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestManager(t *testing.T, now time.Time) *Manager {
+	t.Helper()
+	m, err := NewManager(filepath.Join(t.TempDir(), "keys.json"))
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	m.now = func() time.Time { return now }
+	return m
+}
+
+func TestRotateKeysFirstRotationHasNoRetiredKeys(t *testing.T) {
+	now := time.Now()
+	m := newTestManager(t, now)
+
+	newKey, err := m.RotateKeys(ECP256, time.Hour, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("RotateKeys: %v", err)
+	}
+
+	if newKey.Retired {
+		t.Error("freshly rotated key should not be retired")
+	}
+	if len(m.All()) != 1 {
+		t.Fatalf("got %d keys, want 1", len(m.All()))
+	}
+}
+
+func TestRotateKeysRetiresPreviousKeyWithinGracePeriod(t *testing.T) {
+	now := time.Now()
+	m := newTestManager(t, now)
+
+	first, err := m.RotateKeys(ECP256, time.Hour, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("RotateKeys: %v", err)
+	}
+
+	second, err := m.RotateKeys(ECP256, time.Hour, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("RotateKeys: %v", err)
+	}
+
+	all := m.All()
+	if len(all) != 2 {
+		t.Fatalf("got %d keys, want 2 (one active, one retired within its grace period)", len(all))
+	}
+
+	active, err := m.Active()
+	if err != nil {
+		t.Fatalf("Active: %v", err)
+	}
+	if active.KID != second.KID {
+		t.Errorf("got active key %q, want the most recently rotated key %q", active.KID, second.KID)
+	}
+
+	for _, k := range all {
+		if k.KID == first.KID && !k.Retired {
+			t.Error("the previously active key should be retired after a rotation")
+		}
+	}
+}
+
+func TestRotateKeysDropsExpiredRetiredKeys(t *testing.T) {
+	now := time.Now()
+	m := newTestManager(t, now)
+
+	// First rotation creates the initial key. Second rotation retires it,
+	// starting its grace window from the second rotation's time. Only once
+	// a third rotation happens after that grace window elapses does the
+	// retired key get dropped.
+	first, err := m.RotateKeys(ECP256, time.Hour, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("RotateKeys: %v", err)
+	}
+
+	m.now = func() time.Time { return now.Add(5 * time.Minute) }
+	if _, err := m.RotateKeys(ECP256, time.Hour, 10*time.Minute); err != nil {
+		t.Fatalf("RotateKeys: %v", err)
+	}
+
+	m.now = func() time.Time { return now.Add(20 * time.Minute) } // past first's grace period
+	if _, err := m.RotateKeys(ECP256, time.Hour, 10*time.Minute); err != nil {
+		t.Fatalf("RotateKeys: %v", err)
+	}
+
+	for _, k := range m.All() {
+		if k.KID == first.KID {
+			t.Error("a retired key whose grace period elapsed should have been dropped")
+		}
+	}
+	if len(m.All()) != 2 {
+		t.Fatalf("got %d keys, want 2 (the second and third rotations' keys)", len(m.All()))
+	}
+}
+
+func TestActiveErrorsWithNoKeys(t *testing.T) {
+	m := newTestManager(t, time.Now())
+	if _, err := m.Active(); err == nil {
+		t.Fatal("expected an error when no signing key has been generated yet")
+	}
+}
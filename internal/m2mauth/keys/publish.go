@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2023-Present, Okta, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package keys
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// WellKnownJWKSPath is the path Okta's Service App key registration
+// workflow, and most OIDC tooling in general, expects a JWKS to be
+// published at.
+const WellKnownJWKSPath = "/.well-known/jwks.json"
+
+// Print writes m's JWKS (active + retired public keys) to w as formatted
+// JSON, for `okta-aws-cli m2m jwks --print`.
+func Print(m *Manager, w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m.JWKS())
+}
+
+// serveHeaderTimeout and serveReadTimeout bound how long a client can take
+// sending request headers/body before the server gives up on it. This
+// endpoint is meant to be reachable by Okta over the network, so it needs
+// protection from slow-client (slowloris-style) connections that a bare
+// http.ListenAndServe wouldn't have.
+const (
+	serveHeaderTimeout = 5 * time.Second
+	serveReadTimeout   = 10 * time.Second
+)
+
+// Serve starts an HTTP server on addr that publishes m's JWKS at
+// WellKnownJWKSPath, for `okta-aws-cli m2m jwks --serve`. It blocks until
+// it receives SIGINT or SIGTERM, at which point it shuts down gracefully,
+// or until the server fails to start.
+func Serve(m *Manager, addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(WellKnownJWKSPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(m.JWKS())
+	})
+
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: serveHeaderTimeout,
+		ReadTimeout:       serveReadTimeout,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- srv.ListenAndServe() }()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		return srv.Shutdown(context.Background())
+	}
+}
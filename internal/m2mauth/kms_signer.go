@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2023-Present, Okta, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package m2mauth
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// awsKMSSigner calls AWS KMS's Sign API for an asymmetric KMS key via the
+// AWS SDK, using whatever credential chain the SDK resolves by default.
+type awsKMSSigner struct {
+	svc              *kms.KMS
+	keyID            string
+	kid              string
+	alg              jose.SignatureAlgorithm
+	signingAlgorithm string
+	ecdsaCoordSize   int // 0 for RSA keys
+	publicKeyDER     []byte
+}
+
+// newAWSKMSSigner builds a Signer backed by the AWS KMS key referenced by
+// keyID (a key ID, ARN, or alias such as "alias/okta-m2m"). It resolves the
+// key's spec and public key once so that every Sign call only has to make
+// the KMS Sign API call.
+func newAWSKMSSigner(keyID, kid string, httpClient *http.Client) (Signer, error) {
+	sess, err := session.NewSession(aws.NewConfig().WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, err
+	}
+	svc := kms.New(sess)
+
+	pub, err := svc.GetPublicKey(&kms.GetPublicKeyInput{KeyId: aws.String(keyID)})
+	if err != nil {
+		return nil, fmt.Errorf("describing AWS KMS key %q: %w", keyID, err)
+	}
+
+	s := &awsKMSSigner{svc: svc, keyID: keyID, kid: kid, publicKeyDER: pub.PublicKey}
+	if s.kid == "" {
+		s.kid = keyID
+	}
+
+	switch aws.StringValue(pub.KeySpec) {
+	case kms.KeySpecRsa2048, kms.KeySpecRsa3072, kms.KeySpecRsa4096:
+		s.alg = jose.RS256
+		s.signingAlgorithm = kms.SigningAlgorithmSpecRsassaPkcs1V15Sha256
+	case kms.KeySpecEccNistP256:
+		s.alg = jose.ES256
+		s.signingAlgorithm = kms.SigningAlgorithmSpecEcdsaSha256
+		s.ecdsaCoordSize = 32
+	case kms.KeySpecEccNistP384:
+		s.alg = jose.ES384
+		s.signingAlgorithm = kms.SigningAlgorithmSpecEcdsaSha384
+		s.ecdsaCoordSize = 48
+	case kms.KeySpecEccNistP521:
+		s.alg = jose.ES512
+		s.signingAlgorithm = kms.SigningAlgorithmSpecEcdsaSha512
+		s.ecdsaCoordSize = 66
+	default:
+		return nil, fmt.Errorf("AWS KMS key %q has unsupported key spec %q", keyID, aws.StringValue(pub.KeySpec))
+	}
+
+	return s, nil
+}
+
+func (s *awsKMSSigner) algAndKid() (jose.SignatureAlgorithm, string) {
+	return s.alg, s.kid
+}
+
+// Sign implements Signer by calling the KMS Sign API. ECDSA signatures come
+// back DER encoded; they are converted to the fixed-width raw format JOSE
+// expects before being returned.
+func (s *awsKMSSigner) Sign(payload []byte) ([]byte, jose.SignatureAlgorithm, string, error) {
+	out, err := s.svc.Sign(&kms.SignInput{
+		KeyId:            aws.String(s.keyID),
+		Message:          payload,
+		MessageType:      aws.String(kms.MessageTypeRaw),
+		SigningAlgorithm: aws.String(s.signingAlgorithm),
+	})
+	if err != nil {
+		return nil, "", "", fmt.Errorf("signing with AWS KMS key %q: %w", s.keyID, err)
+	}
+
+	sig := out.Signature
+	if s.ecdsaCoordSize > 0 {
+		sig, err = ecdsaDERToJOSE(sig, s.ecdsaCoordSize)
+		if err != nil {
+			return nil, "", "", err
+		}
+	}
+
+	return sig, s.alg, s.kid, nil
+}
+
+func (s *awsKMSSigner) publicJWK() (*jose.JSONWebKey, error) {
+	pub, err := x509.ParsePKIXPublicKey(s.publicKeyDER)
+	if err != nil {
+		return nil, fmt.Errorf("parsing AWS KMS public key %q: %w", s.keyID, err)
+	}
+	return &jose.JSONWebKey{Key: pub, KeyID: s.kid, Algorithm: string(s.alg), Use: "sig"}, nil
+}
+
+// ecdsaDERToJOSE converts an ASN.1 DER encoded ECDSA signature, as returned
+// by AWS KMS and GCP Cloud KMS, into the fixed-width
+// raw r||s format required by JOSE (RFC 7518 section 3.4).
+func ecdsaDERToJOSE(der []byte, coordSize int) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, fmt.Errorf("parsing DER ECDSA signature: %w", err)
+	}
+
+	raw := make([]byte, 2*coordSize)
+	sig.R.FillBytes(raw[:coordSize])
+	sig.S.FillBytes(raw[coordSize:])
+	return raw, nil
+}
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2023-Present, Okta, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package m2mauth
+
+import (
+	"encoding/asn1"
+	"math/big"
+	"testing"
+)
+
+func TestEcdsaDERToJOSE(t *testing.T) {
+	r := big.NewInt(0).SetBytes([]byte{0x01, 0x02, 0x03})
+	s := big.NewInt(0).SetBytes([]byte{0xaa, 0xbb})
+
+	der, err := asn1.Marshal(struct{ R, S *big.Int }{R: r, S: s})
+	if err != nil {
+		t.Fatalf("marshaling DER fixture: %v", err)
+	}
+
+	raw, err := ecdsaDERToJOSE(der, 32)
+	if err != nil {
+		t.Fatalf("ecdsaDERToJOSE returned error: %v", err)
+	}
+	if len(raw) != 64 {
+		t.Fatalf("got raw signature of length %d, want %d", len(raw), 64)
+	}
+
+	gotR := big.NewInt(0).SetBytes(raw[:32])
+	gotS := big.NewInt(0).SetBytes(raw[32:])
+	if gotR.Cmp(r) != 0 {
+		t.Errorf("got R %x, want %x", gotR, r)
+	}
+	if gotS.Cmp(s) != 0 {
+		t.Errorf("got S %x, want %x", gotS, s)
+	}
+}
+
+func TestEcdsaDERToJOSEInvalidInput(t *testing.T) {
+	if _, err := ecdsaDERToJOSE([]byte("not der"), 32); err == nil {
+		t.Fatal("expected an error for malformed DER input")
+	}
+}
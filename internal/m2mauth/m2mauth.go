@@ -14,11 +14,24 @@
  * limitations under the License.
  */
 
+// Package m2mauth implements the OAuth 2.0 client credentials (machine to
+// machine) flow used to obtain AWS IAM credentials from an Okta Service
+// App: it builds and signs the client assertion (or presents mTLS client
+// auth), exchanges it at the org's token endpoint, and assumes an IAM role
+// with the resulting access token. The exported RotateSigningKeys, JWKS,
+// and SigningPublicJWK methods, and the token-cache behavior gated by
+// config.CacheAccessToken, are library entry points for
+// a CLI layer (`okta-aws-cli m2m jwks`, `m2m rotate-keys`,
+// `--no-token-cache`) to call; that CLI wiring, and the config package's
+// own env var/flag surface, live outside this package.
 package m2mauth
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
@@ -35,6 +48,7 @@ import (
 	"github.com/aws/aws-sdk-go/service/sts"
 	oaws "github.com/okta/okta-aws-cli/internal/aws"
 	"github.com/okta/okta-aws-cli/internal/config"
+	"github.com/okta/okta-aws-cli/internal/m2mauth/keys"
 	"github.com/okta/okta-aws-cli/internal/okta"
 	"github.com/okta/okta-aws-cli/internal/output"
 	"github.com/okta/okta-aws-cli/internal/utils"
@@ -47,6 +61,13 @@ const (
 	DefaultScope = "okta-m2m-access"
 	// DefaultAuthzID The default authorization server id
 	DefaultAuthzID = "default"
+
+	// ClientAuthMethodTLSClientAuth RFC 8705 mutual-TLS client
+	// authentication with a CA issued client certificate.
+	ClientAuthMethodTLSClientAuth = "tls_client_auth"
+	// ClientAuthMethodSelfSignedTLSClientAuth RFC 8705 mutual-TLS client
+	// authentication with a self-signed client certificate.
+	ClientAuthMethodSelfSignedTLSClientAuth = "self_signed_tls_client_auth"
 )
 
 // M2MAuthentication Object structure for headless authentication
@@ -96,6 +117,136 @@ func (m *M2MAuthentication) EstablishIAMCredentials() error {
 	return nil
 }
 
+// signingMethod identifies which of the mutually exclusive ways of
+// producing the client assertion signature m.config configures. Every
+// place that needs to know how okta-aws-cli is signing (building the
+// assertion, publishing a JWKS) resolves it through
+// resolveSigningMethod rather than re-deriving it, so they can never
+// disagree with each other about which key is actually in use.
+type signingMethod int
+
+const (
+	signingMethodPEM signingMethod = iota
+	signingMethodJWK
+	signingMethodKeyStore
+	signingMethodBackend
+)
+
+func (s signingMethod) String() string {
+	switch s {
+	case signingMethodJWK:
+		return "private key JWK"
+	case signingMethodKeyStore:
+		return "key store"
+	case signingMethodBackend:
+		return "signing backend"
+	default:
+		return "private key PEM"
+	}
+}
+
+// resolveSigningMethod determines which single signing method m.config
+// configures. PrivateKey, PrivateKeyJWK, KeyStorePath, and SigningBackend
+// are all mutually exclusive; configuring more than one is an error rather
+// than silently preferring one over the others.
+func (m *M2MAuthentication) resolveSigningMethod() (signingMethod, error) {
+	return resolveSigningMethodFrom(m.config.PrivateKey(), m.config.PrivateKeyJWK(), m.config.KeyStorePath(), m.config.SigningBackend())
+}
+
+// resolveSigningMethodFrom implements resolveSigningMethod's mutual
+// exclusivity check and method selection as a pure function of the four
+// config values involved, so the logic can be tested without a
+// *config.Config.
+func resolveSigningMethodFrom(pemKey, jwkKey, keyStorePath, backend string) (signingMethod, error) {
+	configured := 0
+	for _, v := range []string{pemKey, jwkKey, keyStorePath, backend} {
+		if v != "" {
+			configured++
+		}
+	}
+	if configured > 1 {
+		return 0, errors.New("only one of private key PEM, private key JWK, a key store, or a signing backend may be configured")
+	}
+
+	switch {
+	case backend != "":
+		return signingMethodBackend, nil
+	case keyStorePath != "":
+		return signingMethodKeyStore, nil
+	case jwkKey != "":
+		return signingMethodJWK, nil
+	default:
+		return signingMethodPEM, nil
+	}
+}
+
+// SigningPublicJWK returns the public key of the configured signing
+// backend as a JWK, for the `okta-aws-cli m2m jwks` command to publish so
+// it can be registered with the Okta Service App. It is only available
+// when a signing backend (see SigningBackend) is configured; the other
+// signing methods never have a remote signer to ask for a public key.
+func (m *M2MAuthentication) SigningPublicJWK() (*jose.JSONWebKey, error) {
+	method, err := m.resolveSigningMethod()
+	if err != nil {
+		return nil, err
+	}
+	if method != signingMethodBackend {
+		return nil, fmt.Errorf("signing public JWK requires a signing backend to be configured, not a %s", method)
+	}
+
+	signer, err := NewSigner(m.config.SigningBackend(), m.config.KeyID(), m.config.HTTPClient())
+	if err != nil {
+		return nil, err
+	}
+	return PublicJWK(signer)
+}
+
+// JWKS returns the public keys the `okta-aws-cli m2m jwks` command should
+// publish: the active and retired public keys of a configured rotating key
+// store, or the single public key of a configured signing backend. It
+// resolves the signing method the same way makeClientAssertion does, so it
+// can never publish a key other than the one actually signing tokens.
+func (m *M2MAuthentication) JWKS() (*jose.JSONWebKeySet, error) {
+	method, err := m.resolveSigningMethod()
+	if err != nil {
+		return nil, err
+	}
+
+	switch method {
+	case signingMethodKeyStore:
+		mgr, err := keys.NewManager(m.config.KeyStorePath())
+		if err != nil {
+			return nil, err
+		}
+		set := mgr.JWKS()
+		return &set, nil
+	case signingMethodBackend:
+		jwk, err := m.SigningPublicJWK()
+		if err != nil {
+			return nil, err
+		}
+		return &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{*jwk}}, nil
+	default:
+		return nil, fmt.Errorf("the %s signing method has no public key to publish; configure a signing backend or key store", method)
+	}
+}
+
+// RotateSigningKeys generates a new signing key in the configured rotating
+// key store, retiring the previously active key but keeping it published
+// until gracePeriod elapses, for `okta-aws-cli m2m rotate-keys`.
+func (m *M2MAuthentication) RotateSigningKeys(keyType keys.KeyType, ttl, gracePeriod time.Duration) (*keys.Key, error) {
+	path := m.config.KeyStorePath()
+	if path == "" {
+		return nil, errors.New("rotating signing keys requires a key store (KeyStorePath/OKTA_AWSCLI_KEY_STORE) to be configured")
+	}
+
+	mgr, err := keys.NewManager(path)
+	if err != nil {
+		return nil, err
+	}
+	return mgr.RotateKeys(keyType, ttl, gracePeriod)
+}
+
 func (m *M2MAuthentication) awsAssumeRoleWithWebIdentity(at *okta.AccessToken) (credential *oaws.Credential, err error) {
 	awsCfg := aws.NewConfig().WithHTTPClient(m.config.HTTPClient())
 	sess, err := session.NewSession(awsCfg)
@@ -123,9 +274,59 @@ func (m *M2MAuthentication) awsAssumeRoleWithWebIdentity(at *okta.AccessToken) (
 	return credential, nil
 }
 
+// createKeySigner Builds the JOSE signer used to sign the client assertion
+// JWT for every signing method except signingMethodBackend, which
+// makeClientAssertion handles itself since a remote KMS can't produce a
+// go-jose Signer. The private key can be supplied as a PEM encoded PKCS#1
+// or PKCS#8 key (PrivateKey/OKTA_AWSCLI_PRIVATE_KEY), as a JWK/JWKS
+// (PrivateKeyJWK/OKTA_AWSCLI_PRIVATE_KEY_JWK), or by pointing at a rotating
+// key store managed by the keys package (KeyStorePath/OKTA_AWSCLI_KEY_STORE).
 func (m *M2MAuthentication) createKeySigner() (jose.Signer, error) {
+	method, err := m.resolveSigningMethod()
+	if err != nil {
+		return nil, err
+	}
+
 	signerOptions := (&jose.SignerOptions{}).WithHeader("kid", m.config.KeyID())
-	priv := []byte(strings.ReplaceAll(m.config.PrivateKey(), `\n`, "\n"))
+	switch method {
+	case signingMethodKeyStore:
+		return m.keyStoreSigner(m.config.KeyStorePath())
+	case signingMethodJWK:
+		return m.jwkKeySigner(m.config.PrivateKeyJWK())
+	case signingMethodBackend:
+		return nil, errors.New("createKeySigner cannot build a go-jose Signer for a remote signing backend")
+	default:
+		return m.pemKeySigner(m.config.PrivateKey(), signerOptions)
+	}
+}
+
+// keyStoreSigner Builds a signer from the currently active key in the
+// rotating key store at path (see the keys package).
+func (m *M2MAuthentication) keyStoreSigner(path string) (jose.Signer, error) {
+	mgr, err := keys.NewManager(path)
+	if err != nil {
+		return nil, err
+	}
+
+	active, err := mgr.Active()
+	if err != nil {
+		return nil, fmt.Errorf("loading active signing key from %q: %w", path, err)
+	}
+
+	signerOptions := (&jose.SignerOptions{}).WithHeader("kid", active.KID)
+	return jose.NewSigner(jose.SigningKey{Algorithm: active.Algorithm, Key: active.JWK.Key}, signerOptions)
+}
+
+// normalizePEM Undoes the literal "\n" escaping that PEM material often
+// picks up when passed through an environment variable or flag.
+func normalizePEM(pemStr string) []byte {
+	return []byte(strings.ReplaceAll(pemStr, `\n`, "\n"))
+}
+
+// pemKeySigner Builds a signer from a PEM encoded PKCS#1 or PKCS#8 private
+// key.
+func (m *M2MAuthentication) pemKeySigner(pemKey string, signerOptions *jose.SignerOptions) (jose.Signer, error) {
+	priv := normalizePEM(pemKey)
 
 	privPem, _ := pem.Decode(priv)
 	if privPem == nil {
@@ -145,15 +346,17 @@ func (m *M2MAuthentication) createKeySigner() (jose.Signer, error) {
 			return nil, err
 		}
 		var alg jose.SignatureAlgorithm
-		switch parsedKey.(type) {
+		switch key := parsedKey.(type) {
 		case *rsa.PrivateKey:
 			alg = jose.RS256
 		case *ecdsa.PrivateKey:
-			alg = jose.ES256 // TODO handle ES384 or ES512 ?
+			alg, err = ecdsaSignatureAlgorithm(key.Curve)
+			if err != nil {
+				return nil, err
+			}
+		case ed25519.PrivateKey:
+			alg = jose.EdDSA
 		default:
-			// TODO are either of these also valid?
-			// ed25519.PrivateKey:
-			// *ecdh.PrivateKey
 			return nil, fmt.Errorf("private key %q is unknown pkcs#8 format type", privPem.Type)
 		}
 		return jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: parsedKey}, signerOptions)
@@ -162,12 +365,146 @@ func (m *M2MAuthentication) createKeySigner() (jose.Signer, error) {
 	return nil, fmt.Errorf("private key %q is not pkcs#1 or pkcs#8 format", privPem.Type)
 }
 
-func (m *M2MAuthentication) makeClientAssertion() (string, error) {
-	privateKeySinger, err := m.createKeySigner()
+// jwkKeySigner Builds a signer from a JSON Web Key, or a JSON Web Key Set
+// selected by kid, as supplied via PrivateKeyJWK. The JWS kid header comes
+// from KeyID when configured, falling back to the selected JWK's own kid
+// (the common case: a JWK pasted straight from Okta's Service App key
+// generation already carries the kid Okta expects).
+func (m *M2MAuthentication) jwkKeySigner(jwkKey string) (jose.Signer, error) {
+	jwk, err := selectJWK([]byte(jwkKey), m.config.KeyID())
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
+	alg, err := jwkSignatureAlgorithm(jwk)
+	if err != nil {
+		return nil, err
+	}
+
+	kid := m.config.KeyID()
+	if kid == "" {
+		kid = jwk.KeyID
+	}
+	signerOptions := (&jose.SignerOptions{}).WithHeader("kid", kid)
+
+	return jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: jwk.Key}, signerOptions)
+}
+
+// selectJWK Parses raw as either a single JWK or a JWKS. When raw is a JWKS
+// containing more than one key, kid is required to select the key to sign
+// with.
+func selectJWK(raw []byte, kid string) (*jose.JSONWebKey, error) {
+	var single jose.JSONWebKey
+	if err := json.Unmarshal(raw, &single); err == nil && single.Valid() {
+		return &single, nil
+	}
+
+	var set jose.JSONWebKeySet
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("private key JWK is neither a valid JWK nor a JWKS: %w", err)
+	}
+	if len(set.Keys) == 0 {
+		return nil, errors.New("private key JWKS contains no keys")
+	}
+	if kid == "" {
+		if len(set.Keys) > 1 {
+			return nil, errors.New("private key JWKS has multiple keys but no kid is configured to select one")
+		}
+		return &set.Keys[0], nil
+	}
+	matches := set.Key(kid)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("private key JWKS has no key with kid %q", kid)
+	}
+	return &matches[0], nil
+}
+
+// jwkSignatureAlgorithm Derives the JOSE signature algorithm for a parsed
+// JWK from its underlying key type (and, for EC keys, its curve).
+func jwkSignatureAlgorithm(jwk *jose.JSONWebKey) (jose.SignatureAlgorithm, error) {
+	switch key := jwk.Key.(type) {
+	case *rsa.PrivateKey:
+		return jose.RS256, nil
+	case *ecdsa.PrivateKey:
+		return ecdsaSignatureAlgorithm(key.Curve)
+	case ed25519.PrivateKey:
+		return jose.EdDSA, nil
+	default:
+		return "", fmt.Errorf("private key JWK has unsupported key type %T", key)
+	}
+}
+
+// ecdsaSignatureAlgorithm Maps an EC private key's curve to the JOSE
+// signature algorithm Okta expects for it.
+func ecdsaSignatureAlgorithm(curve elliptic.Curve) (jose.SignatureAlgorithm, error) {
+	switch curve {
+	case elliptic.P256():
+		return jose.ES256, nil
+	case elliptic.P384():
+		return jose.ES384, nil
+	case elliptic.P521():
+		return jose.ES512, nil
+	default:
+		return "", fmt.Errorf("private key uses unsupported elliptic curve %q", curve.Params().Name)
+	}
+}
+
+// mtlsHTTPClient Clones base and attaches the client certificate configured
+// for RFC 8705 mTLS client authentication, leaving everything else about
+// the client (proxy, timeouts, etc.) untouched.
+func (m *M2MAuthentication) mtlsHTTPClient(base *http.Client) (*http.Client, error) {
+	cert, err := m.clientCertificate()
+	if err != nil {
+		return nil, err
+	}
+	return attachClientCertificate(base, cert), nil
+}
+
+// attachClientCertificate returns a shallow copy of base whose transport
+// presents cert for mTLS, cloning rather than mutating base's transport and
+// TLS config so the caller's http.Client is unaffected.
+func attachClientCertificate(base *http.Client, cert tls.Certificate) *http.Client {
+	var transport *http.Transport
+	if t, ok := base.Transport.(*http.Transport); ok && t != nil {
+		transport = t.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+
+	tlsConfig := transport.TLSClientConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+	tlsConfig.Certificates = []tls.Certificate{cert}
+	transport.TLSClientConfig = tlsConfig
+
+	client := *base
+	client.Transport = transport
+	return &client
+}
+
+// clientCertificate Loads the client certificate and matching private key
+// configured for mTLS client authentication, reusing the same PEM
+// normalization createKeySigner's PEM path uses.
+func (m *M2MAuthentication) clientCertificate() (tls.Certificate, error) {
+	return mtlsCertificate(m.config.ClientCertificate(), m.config.PrivateKey())
+}
+
+// mtlsCertificate parses a PEM encoded certificate and private key pair,
+// normalizing escaped newlines the way environment-variable-supplied PEM
+// material often picks up, into a tls.Certificate usable for RFC 8705
+// mTLS client authentication.
+func mtlsCertificate(certPEM, keyPEM string) (tls.Certificate, error) {
+	cert, err := tls.X509KeyPair(normalizePEM(certPEM), normalizePEM(keyPEM))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("loading mTLS client certificate: %w", err)
+	}
+	return cert, nil
+}
+
+func (m *M2MAuthentication) makeClientAssertion() (string, error) {
 	tokenRequestURL := fmt.Sprintf(okta.CustomAuthzV1TokenEndpointFormat, m.config.OrgDomain(), m.config.AuthzID())
 	now := m.config.Clock().Now()
 	claims := okta.ClientAssertionClaims{
@@ -178,16 +515,50 @@ func (m *M2MAuthentication) makeClientAssertion() (string, error) {
 		Audience: tokenRequestURL,
 	}
 
+	method, err := m.resolveSigningMethod()
+	if err != nil {
+		return "", err
+	}
+
+	// A configured signing backend means the private key lives in a remote
+	// KMS; the JWS has to be assembled by hand since go-jose's Signer
+	// requires an in-process key.
+	if method == signingMethodBackend {
+		signer, err := NewSigner(m.config.SigningBackend(), m.config.KeyID(), m.config.HTTPClient())
+		if err != nil {
+			return "", err
+		}
+		return signClientAssertion(signer, claims)
+	}
+
+	privateKeySinger, err := m.createKeySigner()
+	if err != nil {
+		return "", err
+	}
+
 	jwtBuilder := jwt.Signed(privateKeySinger).Claims(claims)
 	return jwtBuilder.CompactSerialize()
 }
 
 // accessToken Takes okta-aws-cli private key and presents a client_credentials
 // flow assertion to /oauth2/{authzServerID}/v1/token to gather an access token.
+// When ClientAuthMethod is configured for mTLS (tls_client_auth or
+// self_signed_tls_client_auth), the client authenticates with its TLS
+// certificate instead, per RFC 8705, and no JWT assertion is built at all.
+// Unless disabled (CacheAccessToken/--no-token-cache), a cached token for
+// the same org/authz server/app/scope is reused instead of round tripping
+// to Okta at all.
 func (m *M2MAuthentication) accessToken() (*okta.AccessToken, error) {
-	clientAssertion, err := m.makeClientAssertion()
-	if err != nil {
-		return nil, err
+	cacheKey := tokenCacheKey{
+		OrgDomain:   m.config.OrgDomain(),
+		AuthzID:     m.config.AuthzID(),
+		OIDCAppID:   m.config.OIDCAppID(),
+		CustomScope: m.config.CustomScope(),
+	}
+	if m.config.CacheAccessToken() {
+		if at, ok := cachedAccessToken(cacheKey); ok {
+			return at, nil
+		}
 	}
 
 	var tokenRequestBuff io.ReadWriter
@@ -196,8 +567,26 @@ func (m *M2MAuthentication) accessToken() (*okta.AccessToken, error) {
 
 	query.Add("grant_type", "client_credentials")
 	query.Add("scope", m.config.CustomScope())
-	query.Add("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
-	query.Add("client_assertion", clientAssertion)
+
+	httpClient := m.config.HTTPClient()
+	switch m.config.ClientAuthMethod() {
+	case ClientAuthMethodTLSClientAuth, ClientAuthMethodSelfSignedTLSClientAuth:
+		query.Add("client_id", m.config.OIDCAppID())
+
+		var err error
+		httpClient, err = m.mtlsHTTPClient(httpClient)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		clientAssertion, err := m.makeClientAssertion()
+		if err != nil {
+			return nil, err
+		}
+		query.Add("client_assertion_type", "urn:ietf:params:oauth:client-assertion-type:jwt-bearer")
+		query.Add("client_assertion", clientAssertion)
+	}
+
 	tokenRequestURL += "?" + query.Encode()
 	req, err := http.NewRequest("POST", tokenRequestURL, tokenRequestBuff)
 	if err != nil {
@@ -207,7 +596,7 @@ func (m *M2MAuthentication) accessToken() (*okta.AccessToken, error) {
 	req.Header.Add(utils.ContentType, utils.ApplicationXFORM)
 	req.Header.Add(utils.UserAgentHeader, config.UserAgentValue)
 	req.Header.Add(utils.XOktaAWSCLIOperationHeader, utils.XOktaAWSCLIM2MOperation)
-	resp, err := m.config.HTTPClient().Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -234,5 +623,12 @@ func (m *M2MAuthentication) accessToken() (*okta.AccessToken, error) {
 		return nil, err
 	}
 
+	if m.config.CacheAccessToken() {
+		// Caching is a latency optimization, not a correctness requirement;
+		// a token we just successfully fetched is still returned even if
+		// persisting it to the cache fails.
+		_ = cacheAccessToken(cacheKey, token)
+	}
+
 	return token, nil
 }
@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2023-Present, Okta, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package m2mauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+func mustJWK(t *testing.T, kid string) jose.JSONWebKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	return jose.JSONWebKey{Key: key, KeyID: kid, Algorithm: string(jose.RS256), Use: "sig"}
+}
+
+func TestSelectJWKSingleKey(t *testing.T) {
+	jwk := mustJWK(t, "key-1")
+	raw, err := json.Marshal(jwk)
+	if err != nil {
+		t.Fatalf("marshaling JWK: %v", err)
+	}
+
+	got, err := selectJWK(raw, "")
+	if err != nil {
+		t.Fatalf("selectJWK returned error: %v", err)
+	}
+	if got.KeyID != "key-1" {
+		t.Errorf("got kid %q, want %q", got.KeyID, "key-1")
+	}
+}
+
+func TestSelectJWKSetRequiresKidWithMultipleKeys(t *testing.T) {
+	set := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{mustJWK(t, "key-1"), mustJWK(t, "key-2")}}
+	raw, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("marshaling JWKS: %v", err)
+	}
+
+	if _, err := selectJWK(raw, ""); err == nil {
+		t.Fatal("expected an error when a JWKS has multiple keys and no kid is given")
+	}
+
+	got, err := selectJWK(raw, "key-2")
+	if err != nil {
+		t.Fatalf("selectJWK returned error: %v", err)
+	}
+	if got.KeyID != "key-2" {
+		t.Errorf("got kid %q, want %q", got.KeyID, "key-2")
+	}
+}
+
+func TestSelectJWKSetUnknownKid(t *testing.T) {
+	set := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{mustJWK(t, "key-1")}}
+	raw, err := json.Marshal(set)
+	if err != nil {
+		t.Fatalf("marshaling JWKS: %v", err)
+	}
+
+	if _, err := selectJWK(raw, "does-not-exist"); err == nil {
+		t.Fatal("expected an error for a kid that is not present in the JWKS")
+	}
+}
+
+func TestSelectJWKInvalidInput(t *testing.T) {
+	if _, err := selectJWK([]byte("not json"), ""); err == nil {
+		t.Fatal("expected an error for input that is neither a JWK nor a JWKS")
+	}
+}
+
+func TestResolveSigningMethodFromPicksConfiguredMethod(t *testing.T) {
+	tests := []struct {
+		name                                  string
+		pemKey, jwkKey, keyStorePath, backend string
+		want                                  signingMethod
+	}{
+		{name: "nothing configured defaults to PEM", want: signingMethodPEM},
+		{name: "PEM configured", pemKey: "-----BEGIN...", want: signingMethodPEM},
+		{name: "JWK configured", jwkKey: "{}", want: signingMethodJWK},
+		{name: "key store configured", keyStorePath: "/tmp/keys.json", want: signingMethodKeyStore},
+		{name: "backend configured", backend: "awskms://alias/okta-m2m", want: signingMethodBackend},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveSigningMethodFrom(tt.pemKey, tt.jwkKey, tt.keyStorePath, tt.backend)
+			if err != nil {
+				t.Fatalf("resolveSigningMethodFrom returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveSigningMethodFromRejectsMultipleConfigured(t *testing.T) {
+	tests := []struct {
+		name                                  string
+		pemKey, jwkKey, keyStorePath, backend string
+	}{
+		{name: "PEM and JWK", pemKey: "-----BEGIN...", jwkKey: "{}"},
+		{name: "JWK and key store", jwkKey: "{}", keyStorePath: "/tmp/keys.json"},
+		{name: "key store and backend", keyStorePath: "/tmp/keys.json", backend: "awskms://alias/okta-m2m"},
+		{name: "all four", pemKey: "-----BEGIN...", jwkKey: "{}", keyStorePath: "/tmp/keys.json", backend: "awskms://alias/okta-m2m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := resolveSigningMethodFrom(tt.pemKey, tt.jwkKey, tt.keyStorePath, tt.backend); err == nil {
+				t.Fatal("expected an error when more than one signing method is configured")
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2023-Present, Okta, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package m2mauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// mustSelfSignedCertPEM generates a throwaway self-signed certificate and
+// its matching EC private key, PEM encoded, for exercising mtlsCertificate
+// without needing real key material on disk.
+func mustSelfSignedCertPEM(t *testing.T) (certPEM, keyPEM string) {
+	t.Helper()
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "okta-aws-cli mTLS test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatalf("creating certificate: %v", err)
+	}
+	certBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		t.Fatalf("marshaling private key: %v", err)
+	}
+	keyBytes := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+
+	return string(certBytes), string(keyBytes)
+}
+
+func TestMTLSCertificateLoadsMatchingPair(t *testing.T) {
+	certPEM, keyPEM := mustSelfSignedCertPEM(t)
+
+	cert, err := mtlsCertificate(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("mtlsCertificate returned error: %v", err)
+	}
+	if len(cert.Certificate) == 0 {
+		t.Fatal("expected a parsed certificate chain")
+	}
+}
+
+func TestMTLSCertificateHandlesEscapedNewlines(t *testing.T) {
+	certPEM, keyPEM := mustSelfSignedCertPEM(t)
+	escapedCert := escapeNewlines(certPEM)
+	escapedKey := escapeNewlines(keyPEM)
+
+	if _, err := mtlsCertificate(escapedCert, escapedKey); err != nil {
+		t.Fatalf("mtlsCertificate returned error for escaped-newline PEM: %v", err)
+	}
+}
+
+func TestMTLSCertificateMismatchedKeyErrors(t *testing.T) {
+	certPEM, _ := mustSelfSignedCertPEM(t)
+	_, otherKeyPEM := mustSelfSignedCertPEM(t)
+
+	if _, err := mtlsCertificate(certPEM, otherKeyPEM); err == nil {
+		t.Fatal("expected an error pairing a certificate with a non-matching private key")
+	}
+}
+
+func escapeNewlines(s string) string {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			out = append(out, '\\', 'n')
+			continue
+		}
+		out = append(out, s[i])
+	}
+	return string(out)
+}
+
+func TestAttachClientCertificateClonesTransportAndPreservesBase(t *testing.T) {
+	certPEM, keyPEM := mustSelfSignedCertPEM(t)
+	cert, err := mtlsCertificate(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("mtlsCertificate returned error: %v", err)
+	}
+
+	baseTransport := &http.Transport{}
+	base := &http.Client{Transport: baseTransport}
+
+	got := attachClientCertificate(base, cert)
+
+	gotTransport, ok := got.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("got Transport of type %T, want *http.Transport", got.Transport)
+	}
+	if gotTransport == baseTransport {
+		t.Fatal("attachClientCertificate must clone the transport, not mutate base's")
+	}
+	if len(gotTransport.TLSClientConfig.Certificates) != 1 {
+		t.Fatalf("got %d client certificates, want 1", len(gotTransport.TLSClientConfig.Certificates))
+	}
+	// http.Transport.Clone lazily initializes TLSClientConfig on the
+	// receiver as a side effect (to pin down its HTTP/2 NextProtos
+	// default), so base's TLSClientConfig may be non-nil here even though
+	// attachClientCertificate never touches it directly. What matters is
+	// that base was never handed the client certificate.
+	if baseTransport.TLSClientConfig != nil && len(baseTransport.TLSClientConfig.Certificates) != 0 {
+		t.Fatal("attachClientCertificate must not attach the client certificate to base's transport")
+	}
+	if gotTransport.TLSClientConfig == baseTransport.TLSClientConfig {
+		t.Fatal("attachClientCertificate must not share a TLS config instance with base's transport")
+	}
+}
+
+func TestAttachClientCertificateDefaultsTransport(t *testing.T) {
+	certPEM, keyPEM := mustSelfSignedCertPEM(t)
+	cert, err := mtlsCertificate(certPEM, keyPEM)
+	if err != nil {
+		t.Fatalf("mtlsCertificate returned error: %v", err)
+	}
+
+	got := attachClientCertificate(&http.Client{}, cert)
+
+	transport, ok := got.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("got Transport of type %T, want *http.Transport", got.Transport)
+	}
+	if len(transport.TLSClientConfig.Certificates) != 1 {
+		t.Fatalf("got %d client certificates, want 1", len(transport.TLSClientConfig.Certificates))
+	}
+}
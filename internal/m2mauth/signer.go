@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2023-Present, Okta, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package m2mauth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// Signer abstracts signing of the client assertion JWT. The default signer
+// (see createKeySigner) holds the private key in process; a Signer lets the
+// key instead live in a remote KMS so it never has to be written to disk or
+// passed through an environment variable.
+type Signer interface {
+	// Sign returns the JWS signature over payload along with the signature
+	// algorithm and kid the caller should publish in the JWS header.
+	Sign(payload []byte) (signature []byte, alg jose.SignatureAlgorithm, kid string, err error)
+}
+
+// algAndKidResolver is implemented by Signers that already know their
+// algorithm and kid without needing to perform a signing operation, so
+// makeClientAssertion can build the JWS header without a throwaway Sign
+// call.
+type algAndKidResolver interface {
+	algAndKid() (alg jose.SignatureAlgorithm, kid string)
+}
+
+// NewSigner builds a Signer for backend, a URI identifying where the
+// signing key lives, e.g.:
+//
+//   - awskms://<key id, ARN, or alias, such as alias/okta-m2m>
+//   - azurekv://<vault name>/<key name>[/<key version>]
+//   - gcpkms://<full KMS CryptoKeyVersion resource name>
+//   - vault://<transit key name>
+//
+// kid overrides the kid published in the JWS header; when empty a
+// reasonable default derived from the backend is used.
+func NewSigner(backend, kid string, httpClient *http.Client) (Signer, error) {
+	scheme, rest, ok := strings.Cut(backend, "://")
+	if !ok {
+		return nil, fmt.Errorf("signing backend %q is missing a scheme (awskms://, azurekv://, gcpkms://, vault://)", backend)
+	}
+
+	switch scheme {
+	case "awskms":
+		return newAWSKMSSigner(rest, kid, httpClient)
+	case "azurekv":
+		return newAzureKeyVaultSigner(rest, kid, httpClient)
+	case "gcpkms":
+		return newGCPKMSSigner(rest, kid, httpClient)
+	case "vault":
+		return newVaultTransitSigner(rest, kid, httpClient)
+	default:
+		return nil, fmt.Errorf("signing backend %q has unknown scheme %q", backend, scheme)
+	}
+}
+
+// signClientAssertion assembles a compact JWS over claims by hand, since
+// go-jose's Signer requires an in-process key, and signer may instead be
+// talking to a remote KMS.
+func signClientAssertion(signer Signer, claims interface{}) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	alg, kid, err := resolveAlgAndKid(signer)
+	if err != nil {
+		return "", err
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": string(alg), "kid": kid, "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	sig, _, _, err := signer.Sign([]byte(signingInput))
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// resolveAlgAndKid returns the algorithm and kid a signer will use without
+// necessarily performing a remote signing operation.
+func resolveAlgAndKid(signer Signer) (jose.SignatureAlgorithm, string, error) {
+	if r, ok := signer.(algAndKidResolver); ok {
+		alg, kid := r.algAndKid()
+		return alg, kid, nil
+	}
+
+	_, alg, kid, err := signer.Sign(nil)
+	if err != nil {
+		return "", "", fmt.Errorf("resolving signer algorithm and kid: %w", err)
+	}
+	return alg, kid, nil
+}
+
+// PublicJWK returns the public key of signer as a JWK suitable for
+// publishing to Okta's Service App key registration workflow (see the
+// `okta-aws-cli m2m jwks` command). Only signers that expose a public key,
+// i.e. the remote KMS backed ones, support this.
+func PublicJWK(signer Signer) (*jose.JSONWebKey, error) {
+	p, ok := signer.(publicKeyProvider)
+	if !ok {
+		return nil, fmt.Errorf("signer %T does not expose a public key", signer)
+	}
+	return p.publicJWK()
+}
+
+// publicKeyProvider is implemented by Signers that can expose their public
+// key as a JWK.
+type publicKeyProvider interface {
+	publicJWK() (*jose.JSONWebKey, error)
+}
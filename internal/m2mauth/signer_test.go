@@ -0,0 +1,40 @@
+/*
+ * Copyright (c) 2023-Present, Okta, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package m2mauth
+
+import "testing"
+
+func TestNewSignerRejectsBackendMissingScheme(t *testing.T) {
+	if _, err := NewSigner("alias/okta-m2m", "", nil); err == nil {
+		t.Fatal("expected an error for a backend URI with no scheme")
+	}
+}
+
+func TestNewSignerRejectsUnknownScheme(t *testing.T) {
+	if _, err := NewSigner("hsm://some-key", "", nil); err == nil {
+		t.Fatal("expected an error for a backend URI with an unrecognized scheme")
+	}
+}
+
+func TestNewSignerRejectsVaultBackendWithoutEnv(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "")
+	t.Setenv("VAULT_TOKEN", "")
+
+	if _, err := NewSigner("vault://okta-m2m", "", nil); err == nil {
+		t.Fatal("expected an error dispatching to the vault backend without VAULT_ADDR/VAULT_TOKEN set")
+	}
+}
@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) 2023-Present, Okta, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package m2mauth
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/okta/okta-aws-cli/internal/okta"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// tokenCachePath is where cached M2M access tokens are persisted, mirroring
+// the ~/.okta convention Okta's other SDKs and tools use for local state.
+const tokenCachePath = ".okta/awscli-token-cache.json"
+
+// tokenCacheKey identifies which cached access token, if any, is valid for
+// a given request. Access tokens are scoped to all four of these, so a
+// cache hit requires all four to match.
+type tokenCacheKey struct {
+	OrgDomain   string `json:"org_domain"`
+	AuthzID     string `json:"authz_id"`
+	OIDCAppID   string `json:"oidc_app_id"`
+	CustomScope string `json:"custom_scope"`
+}
+
+type tokenCacheEntry struct {
+	tokenCacheKey
+	AccessToken okta.AccessToken `json:"access_token"`
+	ExpiresAt   time.Time        `json:"expires_at"`
+}
+
+// cachedAccessToken looks up a cached, still-valid access token for key.
+func cachedAccessToken(key tokenCacheKey) (*okta.AccessToken, bool) {
+	entries, err := readTokenCacheFile()
+	if err != nil {
+		return nil, false
+	}
+
+	for _, e := range entries {
+		if e.tokenCacheKey == key && time.Now().Before(e.ExpiresAt) {
+			at := e.AccessToken
+			return &at, true
+		}
+	}
+	return nil, false
+}
+
+// cacheAccessToken persists at under key, replacing any existing entry for
+// the same key and dropping expired entries for other keys along the way.
+func cacheAccessToken(key tokenCacheKey, at *okta.AccessToken) error {
+	entries, err := readTokenCacheFile()
+	if err != nil {
+		entries = nil
+	}
+
+	now := time.Now()
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.tokenCacheKey == key || now.After(e.ExpiresAt) {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	kept = append(kept, tokenCacheEntry{tokenCacheKey: key, AccessToken: *at, ExpiresAt: accessTokenExpiry(now, at)})
+
+	return writeTokenCacheFile(kept)
+}
+
+// accessTokenExpiry determines when at stops being usable, preferring the
+// "exp" claim of the access token itself when it is a JWT (the common case
+// for Okta custom authorization servers), falling back to expires_in
+// relative to now.
+func accessTokenExpiry(now time.Time, at *okta.AccessToken) time.Time {
+	if tok, err := jwt.ParseSigned(at.AccessToken); err == nil {
+		var claims jwt.Claims
+		if err := tok.UnsafeClaimsWithoutVerification(&claims); err == nil && claims.Expiry != nil {
+			return claims.Expiry.Time()
+		}
+	}
+	return now.Add(time.Duration(at.ExpiresIn) * time.Second)
+}
+
+func tokenCacheFilePath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, tokenCachePath), nil
+}
+
+func readTokenCacheFile() ([]tokenCacheEntry, error) {
+	path, err := tokenCacheFilePath()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []tokenCacheEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func writeTokenCacheFile(entries []tokenCacheEntry) error {
+	path, err := tokenCacheFilePath()
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
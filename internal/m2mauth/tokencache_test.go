@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2023-Present, Okta, Inc.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package m2mauth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/okta/okta-aws-cli/internal/okta"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func TestAccessTokenExpiryPrefersJWTExp(t *testing.T) {
+	now := time.Now()
+	exp := now.Add(2 * time.Hour)
+
+	key, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte("test-signing-key-------------")}, nil)
+	if err != nil {
+		t.Fatalf("building signer: %v", err)
+	}
+	raw, err := jwt.Signed(key).Claims(jwt.Claims{Expiry: jwt.NewNumericDate(exp)}).CompactSerialize()
+	if err != nil {
+		t.Fatalf("signing JWT fixture: %v", err)
+	}
+
+	at := &okta.AccessToken{AccessToken: raw, ExpiresIn: 60} // expires_in deliberately disagrees with exp
+	got := accessTokenExpiry(now, at)
+
+	// JWT NumericDate has second precision, so compare at that granularity.
+	if !got.Equal(exp.Truncate(time.Second)) {
+		t.Errorf("got expiry %v, want the JWT's exp claim %v", got, exp.Truncate(time.Second))
+	}
+}
+
+func TestAccessTokenExpiryFallsBackToExpiresIn(t *testing.T) {
+	now := time.Now()
+	at := &okta.AccessToken{AccessToken: "not-a-jwt", ExpiresIn: 300}
+
+	got := accessTokenExpiry(now, at)
+	want := now.Add(300 * time.Second)
+	if !got.Equal(want) {
+		t.Errorf("got expiry %v, want %v", got, want)
+	}
+}